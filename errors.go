@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+// API error codes. Consumers should branch on Code, not Message, since the
+// message text may change.
+const (
+	ErrCodeInvalidDate      = "INVALID_DATE"
+	ErrCodeInvalidTime      = "INVALID_TIME"
+	ErrCodeMissingParameter = "MISSING_PARAMETER"
+	ErrCodeStationNotFound  = "STATION_NOT_FOUND"
+	ErrCodeNoTrips          = "NO_TRIPS"
+	ErrCodeNoJourneys       = "NO_JOURNEYS"
+	ErrCodeFeedUnavailable  = "FEED_UNAVAILABLE"
+	ErrCodeInternal         = "INTERNAL"
+)
+
+// APIError is the JSON error body every handler returns, so consumers can
+// distinguish error classes without parsing message text.
+type APIError struct {
+	Code    string         `json:"code"`
+	Message string         `json:"message"`
+	Details map[string]any `json:"details,omitempty"`
+	status  int
+}
+
+func (e *APIError) Error() string {
+	return e.Message
+}
+
+// newHTTPError builds a client-facing APIError for the given HTTP status.
+func newHTTPError(status int, code, message string) *APIError {
+	return &APIError{Code: code, Message: message, status: status}
+}
+
+// newDecodeError wraps a GTFS feed parse/load failure. The underlying cause
+// is logged here so operators can diagnose it; only the stable
+// FEED_UNAVAILABLE code reaches the client.
+func newDecodeError(cause error) *APIError {
+	log.Printf("gtfs: feed load failed: %v", cause)
+	return &APIError{
+		Code:    ErrCodeFeedUnavailable,
+		Message: "GTFS feed is temporarily unavailable",
+		status:  http.StatusServiceUnavailable,
+	}
+}
+
+// writeError renders err as a JSON APIError. Errors that aren't already an
+// *APIError are treated as internal: logged in full, exposed to the client
+// only as a generic 500.
+func writeError(w http.ResponseWriter, err error) {
+	apiErr, ok := err.(*APIError)
+	if !ok {
+		log.Printf("internal error: %v", err)
+		apiErr = newHTTPError(http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(apiErr.status)
+	json.NewEncoder(w).Encode(apiErr)
+}
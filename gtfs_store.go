@@ -0,0 +1,211 @@
+package main
+
+import (
+	"log"
+	"os"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// defaultGTFSRefreshInterval is how often the store re-parses gtfs/*.txt in
+// the background when no GTFS_REFRESH_INTERVAL override is set.
+const defaultGTFSRefreshInterval = 5 * time.Minute
+
+// GTFSStore holds the entire static feed parsed once and indexed for O(1)
+// lookups, replacing the per-request loadStops/loadStopTimes/loadTrips/
+// loadCalendar scans.
+type GTFSStore struct {
+	tripsByID          map[string]Trip
+	stopsByID          map[string]Stop
+	stopTimesByTrip    map[string][]StopTime // sorted by StopSequence
+	stopsByName        map[string][]string   // stop_name -> stop_ids
+	calendarsByService map[string]Calendar
+	calendarExceptions map[serviceDateKey]int // (service_id, YYYYMMDD) -> exception_type
+}
+
+// serviceDateKey indexes calendar_dates.txt rows by service and date.
+type serviceDateKey struct {
+	ServiceID string
+	Date      string // YYYYMMDD
+}
+
+// loadGTFSStore parses gtfs/*.txt from disk and builds the indexed store.
+// Load failures are wrapped in an APIError so a caller serving an HTTP
+// request at startup never leaks raw filesystem/CSV errors to the client.
+func loadGTFSStore() (*GTFSStore, error) {
+	stops, err := loadStops()
+	if err != nil {
+		return nil, newDecodeError(err)
+	}
+
+	stopTimes, err := loadStopTimes()
+	if err != nil {
+		return nil, newDecodeError(err)
+	}
+
+	trips, err := loadTrips()
+	if err != nil {
+		return nil, newDecodeError(err)
+	}
+
+	calendars, err := loadCalendar()
+	if err != nil {
+		return nil, newDecodeError(err)
+	}
+
+	calendarDates, err := loadCalendarDates()
+	if err != nil {
+		return nil, newDecodeError(err)
+	}
+
+	store := &GTFSStore{
+		tripsByID:          make(map[string]Trip, len(trips)),
+		stopsByID:          stops,
+		stopTimesByTrip:    make(map[string][]StopTime),
+		stopsByName:        make(map[string][]string),
+		calendarsByService: make(map[string]Calendar, len(calendars)),
+		calendarExceptions: make(map[serviceDateKey]int, len(calendarDates)),
+	}
+
+	for _, trip := range trips {
+		store.tripsByID[trip.TripID] = trip
+	}
+
+	for _, st := range stopTimes {
+		store.stopTimesByTrip[st.TripID] = append(store.stopTimesByTrip[st.TripID], st)
+	}
+	for tripID, sts := range store.stopTimesByTrip {
+		sort.Slice(sts, func(i, j int) bool {
+			return sts[i].StopSequence < sts[j].StopSequence
+		})
+		store.stopTimesByTrip[tripID] = sts
+	}
+
+	for _, stop := range stops {
+		store.stopsByName[stop.StopName] = append(store.stopsByName[stop.StopName], stop.StopID)
+	}
+
+	for _, cal := range calendars {
+		store.calendarsByService[cal.ServiceID] = cal
+	}
+
+	for _, cd := range calendarDates {
+		store.calendarExceptions[serviceDateKey{cd.ServiceID, cd.Date}] = cd.ExceptionType
+	}
+
+	return store, nil
+}
+
+// Trip returns the trip with the given ID.
+func (s *GTFSStore) Trip(tripID string) (Trip, bool) {
+	trip, ok := s.tripsByID[tripID]
+	return trip, ok
+}
+
+// Trips returns every trip in the feed. Callers should not mutate the result.
+func (s *GTFSStore) Trips() []Trip {
+	trips := make([]Trip, 0, len(s.tripsByID))
+	for _, trip := range s.tripsByID {
+		trips = append(trips, trip)
+	}
+	return trips
+}
+
+// Stop returns the stop with the given ID.
+func (s *GTFSStore) Stop(stopID string) (Stop, bool) {
+	stop, ok := s.stopsByID[stopID]
+	return stop, ok
+}
+
+// StopIDsByName returns the stop IDs sharing the given stop_name.
+func (s *GTFSStore) StopIDsByName(name string) []string {
+	return s.stopsByName[name]
+}
+
+// Stops returns every stop in the feed. Callers should not mutate the result.
+func (s *GTFSStore) Stops() []Stop {
+	stops := make([]Stop, 0, len(s.stopsByID))
+	for _, stop := range s.stopsByID {
+		stops = append(stops, stop)
+	}
+	return stops
+}
+
+// StopTimesForTrip returns the stop times of a trip, sorted by StopSequence.
+func (s *GTFSStore) StopTimesForTrip(tripID string) []StopTime {
+	return s.stopTimesByTrip[tripID]
+}
+
+// Calendar returns the calendar.txt row for a service ID.
+func (s *GTFSStore) Calendar(serviceID string) (Calendar, bool) {
+	cal, ok := s.calendarsByService[serviceID]
+	return cal, ok
+}
+
+// CalendarException returns the calendar_dates.txt exception type (1 =
+// added, 2 = removed) for a service on a given YYYYMMDD date, if any.
+func (s *GTFSStore) CalendarException(serviceID, date string) (int, bool) {
+	exceptionType, ok := s.calendarExceptions[serviceDateKey{serviceID, date}]
+	return exceptionType, ok
+}
+
+// GTFSStoreManager owns the current GTFSStore and refreshes it in the
+// background so handlers never block on disk parsing.
+type GTFSStoreManager struct {
+	current atomic.Pointer[GTFSStore]
+}
+
+// NewGTFSStoreManager parses the feed once, then refreshes it on interval in
+// the background until the returned stop function is called.
+func NewGTFSStoreManager(interval time.Duration) (mgr *GTFSStoreManager, stop func(), err error) {
+	store, err := loadGTFSStore()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	mgr = &GTFSStoreManager{}
+	mgr.current.Store(store)
+
+	done := make(chan struct{})
+	go mgr.refreshLoop(interval, done)
+
+	return mgr, func() { close(done) }, nil
+}
+
+// Get returns the most recently loaded store. Safe for concurrent use.
+func (m *GTFSStoreManager) Get() *GTFSStore {
+	return m.current.Load()
+}
+
+func (m *GTFSStoreManager) refreshLoop(interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			store, err := loadGTFSStore()
+			if err != nil {
+				log.Printf("gtfs: background refresh failed: %v", err)
+				continue
+			}
+			m.current.Store(store)
+			log.Println("gtfs: store refreshed")
+		case <-done:
+			return
+		}
+	}
+}
+
+// gtfsRefreshInterval resolves the refresh interval from GTFS_REFRESH_INTERVAL
+// (a duration string such as "30s" or "5m"), falling back to the default.
+func gtfsRefreshInterval() time.Duration {
+	if raw := os.Getenv("GTFS_REFRESH_INTERVAL"); raw != "" {
+		if d, err := time.ParseDuration(raw); err == nil {
+			return d
+		}
+		log.Printf("gtfs: invalid GTFS_REFRESH_INTERVAL %q, using default %s", raw, defaultGTFSRefreshInterval)
+	}
+	return defaultGTFSRefreshInterval
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultMaxTransfers    = 2
+	defaultMinTransferSecs = 300
+
+	// maxAllowedTransfers is the largest caller-supplied maxTransfers
+	// accepted; a larger value is ignored and the default is used.
+	// planJourney allocates maxTransfers+2 arrival and parent maps and
+	// rescans every active trip in each of maxTransfers+1 rounds, so letting
+	// this grow unbounded turns a single request into a CPU/memory
+	// exhaustion vector against this unauthenticated endpoint.
+	maxAllowedTransfers = 10
+
+	// maxAllowedMinTransferSecs bounds minTransferSeconds at a full day, well
+	// beyond any realistic connection window, for the same reason; a larger
+	// value is ignored and the default is used.
+	maxAllowedMinTransferSecs = 86400
+)
+
+// Leg is one boarding of a single trip within a journey.
+type Leg struct {
+	TripID     string `json:"trip_id"`
+	BoardStop  string `json:"board_stop"`
+	BoardTime  string `json:"board_time"`
+	AlightStop string `json:"alight_stop"`
+	AlightTime string `json:"alight_time"`
+}
+
+// Itinerary is one Pareto-optimal way of getting from the origin to the
+// destination: no other itinerary reaches the destination both earlier and
+// with no more transfers.
+type Itinerary struct {
+	Transfers   int    `json:"transfers"`
+	ArrivalTime string `json:"arrival_time"`
+	Legs        []Leg  `json:"legs"`
+}
+
+// planJourney runs a RAPTOR-style round-based connection search: round k
+// holds, for every stop, the earliest arrival time reachable using at most k
+// trips, i.e. at most k-1 transfers. Round k+1 is seeded from round k and improved by
+// scanning every trip active on date for stops already reached in round k.
+// Rounds run through maxTransfers+1 (maxTransfers+1 trips = maxTransfers
+// transfers) so the caller's maxTransfers is honored in transfers, not trips.
+func planJourney(store *GTFSStore, date time.Time, fromStopID, toStopID string, departAfterSeconds, maxTransfers, minTransferSeconds int) []Itinerary {
+	maxRounds := maxTransfers + 1
+	arrival := make([]map[string]int, maxRounds+1)
+	parent := make([]map[string]Leg, maxRounds+1)
+
+	arrival[0] = map[string]int{fromStopID: departAfterSeconds}
+	parent[0] = map[string]Leg{}
+
+	trips := store.Trips()
+	for k := 1; k <= maxRounds; k++ {
+		arrival[k] = copyArrival(arrival[k-1])
+		parent[k] = copyParent(parent[k-1])
+
+		for _, trip := range trips {
+			if !isValidTrip(trip, date, store) {
+				continue
+			}
+			scanTripForRound(store.StopTimesForTrip(trip.TripID), trip.TripID, arrival[k-1], arrival[k], parent[k], minTransferSeconds)
+		}
+	}
+
+	var itineraries []Itinerary
+	bestArrival := int(^uint(0) >> 1) // max int
+	for k := 0; k <= maxRounds; k++ {
+		arr, ok := arrival[k][toStopID]
+		if !ok || arr >= bestArrival {
+			continue
+		}
+		bestArrival = arr
+
+		legs := reconstructLegs(parent, k, fromStopID, toStopID)
+		if len(legs) == 0 {
+			continue // destination is the origin itself; nothing to ride
+		}
+		itineraries = append(itineraries, Itinerary{
+			Transfers:   len(legs) - 1,
+			ArrivalTime: AdjustTime(secondsToHMS(arr)),
+			Legs:        legs,
+		})
+	}
+
+	return itineraries
+}
+
+// scanTripForRound walks one trip's stops in sequence order, boarding at the
+// earliest stop reachable by prevArrival (round k-1) at least
+// minTransferSeconds before departure, and improving thisArrival at every
+// subsequent stop.
+func scanTripForRound(stopTimes []StopTime, tripID string, prevArrival, thisArrival map[string]int, thisParent map[string]Leg, minTransferSeconds int) {
+	boarded := false
+	var boardStopID string
+	var boardTime int
+
+	for _, st := range stopTimes {
+		depSec, depOK := timeToSeconds(st.DepartureTime)
+		arrSec, arrOK := timeToSeconds(st.ArrivalTime)
+
+		if !boarded && depOK {
+			if reachedAt, ok := prevArrival[st.StopID]; ok && reachedAt+minTransferSeconds <= depSec {
+				boarded = true
+				boardStopID = st.StopID
+				boardTime = depSec
+			}
+		}
+
+		if boarded && arrOK && st.StopID != boardStopID {
+			if existing, ok := thisArrival[st.StopID]; !ok || arrSec < existing {
+				thisArrival[st.StopID] = arrSec
+				thisParent[st.StopID] = Leg{
+					TripID:     tripID,
+					BoardStop:  boardStopID,
+					BoardTime:  AdjustTime(secondsToHMS(boardTime)),
+					AlightStop: st.StopID,
+					AlightTime: AdjustTime(secondsToHMS(arrSec)),
+				}
+			}
+		}
+	}
+}
+
+// reconstructLegs walks the parent pointers for round k backwards from
+// toStopID until it reaches fromStopID, returning the legs in travel order.
+func reconstructLegs(parent []map[string]Leg, k int, fromStopID, toStopID string) []Leg {
+	var legs []Leg
+	stop := toStopID
+
+	for r := k; r > 0 && stop != fromStopID; r-- {
+		leg, ok := parent[r][stop]
+		if !ok {
+			break
+		}
+		legs = append([]Leg{leg}, legs...)
+		stop = leg.BoardStop
+	}
+
+	return legs
+}
+
+func copyArrival(m map[string]int) map[string]int {
+	out := make(map[string]int, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
+
+func copyParent(m map[string]Leg) map[string]Leg {
+	out := make(map[string]Leg, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
+
+// timeToSeconds parses a GTFS HH:MM:SS time (hours may exceed 24 for
+// past-midnight trips) into seconds since midnight, without wrapping, so
+// times stay comparable across the day boundary.
+func timeToSeconds(t string) (int, bool) {
+	parts := strings.Split(t, ":")
+	if len(parts) != 3 {
+		return 0, false
+	}
+
+	hours, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, false
+	}
+	minutes, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, false
+	}
+	seconds, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return 0, false
+	}
+
+	return hours*3600 + minutes*60 + seconds, true
+}
+
+// secondsToHMS is the inverse of timeToSeconds, formatting seconds since
+// midnight back into an HH:MM:SS string (hours may exceed 24).
+func secondsToHMS(totalSeconds int) string {
+	hours := totalSeconds / 3600
+	minutes := (totalSeconds % 3600) / 60
+	seconds := totalSeconds % 60
+	return fmt.Sprintf("%02d:%02d:%02d", hours, minutes, seconds)
+}
+
+// parseDepartAfter parses an "HH:MM" time of day into seconds since
+// midnight.
+func parseDepartAfter(raw string) (int, bool) {
+	parts := strings.Split(raw, ":")
+	if len(parts) != 2 {
+		return 0, false
+	}
+	hours, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, false
+	}
+	minutes, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, false
+	}
+	return hours*3600 + minutes*60, true
+}
+
+// handlePlanJourney answers /planJourney?from=...&to=...&date=...&departAfter=HH:MM[&maxTransfers=N&minTransferSeconds=N]
+func handlePlanJourney(w http.ResponseWriter, r *http.Request) {
+	log.Println("request received for /planJourney")
+
+	from := r.URL.Query().Get("from")
+	to := r.URL.Query().Get("to")
+	date := r.URL.Query().Get("date")
+	departAfter := r.URL.Query().Get("departAfter")
+
+	parsedDate, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		writeError(w, newHTTPError(http.StatusBadRequest, ErrCodeInvalidDate, "Invalid date format. Use YYYY-MM-DD"))
+		return
+	}
+
+	departAfterSeconds, ok := parseDepartAfter(departAfter)
+	if !ok {
+		writeError(w, newHTTPError(http.StatusBadRequest, ErrCodeInvalidTime, "Invalid departAfter format. Use HH:MM"))
+		return
+	}
+
+	maxTransfers := defaultMaxTransfers
+	if raw := r.URL.Query().Get("maxTransfers"); raw != "" {
+		if n, err := strconv.Atoi(raw); err == nil && n >= 0 && n <= maxAllowedTransfers {
+			maxTransfers = n
+		}
+	}
+
+	minTransferSeconds := defaultMinTransferSecs
+	if raw := r.URL.Query().Get("minTransferSeconds"); raw != "" {
+		if n, err := strconv.Atoi(raw); err == nil && n >= 0 && n <= maxAllowedMinTransferSecs {
+			minTransferSeconds = n
+		}
+	}
+
+	store := gtfsStore.Get()
+
+	// Prefer an explicit stopID over a name lookup when the caller already
+	// has one.
+	fromID := r.URL.Query().Get("fromStopID")
+	if fromID == "" {
+		if ids := store.StopIDsByName(from); len(ids) > 0 {
+			fromID = ids[0]
+		}
+	}
+	toID := r.URL.Query().Get("toStopID")
+	if toID == "" {
+		if ids := store.StopIDsByName(to); len(ids) > 0 {
+			toID = ids[0]
+		}
+	}
+	if fromID == "" || toID == "" {
+		writeError(w, newHTTPError(http.StatusNotFound, ErrCodeStationNotFound, "From or To station not found"))
+		return
+	}
+
+	itineraries := planJourney(store, parsedDate, fromID, toID, departAfterSeconds, maxTransfers, minTransferSeconds)
+	if len(itineraries) == 0 {
+		writeError(w, newHTTPError(http.StatusNotFound, ErrCodeNoJourneys, "No journeys found"))
+		return
+	}
+
+	response := struct {
+		From        string      `json:"from"`
+		To          string      `json:"to"`
+		Date        string      `json:"date"`
+		Itineraries []Itinerary `json:"itineraries"`
+	}{
+		From:        from,
+		To:          to,
+		Date:        parsedDate.Format("2006-01-02"),
+		Itineraries: itineraries,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// newTestStore builds a minimal GTFSStore for a single weekday service with
+// a fixed set of trips, bypassing disk loading.
+func newTestStore(weekday time.Weekday, trips []Trip, stopTimes []StopTime) *GTFSStore {
+	var weekdays [7]bool
+	weekdays[(int(weekday)+6)%7] = true // Weekdays is Monday-first
+
+	store := &GTFSStore{
+		tripsByID:       make(map[string]Trip, len(trips)),
+		stopsByID:       map[string]Stop{},
+		stopTimesByTrip: make(map[string][]StopTime),
+		stopsByName:     map[string][]string{},
+		calendarsByService: map[string]Calendar{
+			"S1": {
+				ServiceID: "S1",
+				StartDate: "20250101",
+				EndDate:   "20261231",
+				Weekdays:  weekdays,
+			},
+		},
+		calendarExceptions: map[serviceDateKey]int{},
+	}
+
+	for _, trip := range trips {
+		store.tripsByID[trip.TripID] = trip
+	}
+	for _, st := range stopTimes {
+		store.stopTimesByTrip[st.TripID] = append(store.stopTimesByTrip[st.TripID], st)
+	}
+
+	return store
+}
+
+// TestPlanJourneyExactTransferCount builds a 3-trip chain A->B->C->D
+// requiring exactly 2 transfers, with 30-minute transfer windows, and
+// verifies planJourney finds it when maxTransfers==2 but not when
+// maxTransfers==1.
+func TestPlanJourneyExactTransferCount(t *testing.T) {
+	date := time.Date(2026, 1, 5, 0, 0, 0, 0, time.UTC) // a Monday
+
+	trips := []Trip{
+		{TripID: "T1", RouteID: "R1", ServiceID: "S1"},
+		{TripID: "T2", RouteID: "R1", ServiceID: "S1"},
+		{TripID: "T3", RouteID: "R1", ServiceID: "S1"},
+	}
+	stopTimes := []StopTime{
+		{TripID: "T1", StopID: "A", DepartureTime: "08:00:00", ArrivalTime: "08:00:00", StopSequence: 1},
+		{TripID: "T1", StopID: "B", DepartureTime: "08:30:00", ArrivalTime: "08:30:00", StopSequence: 2},
+		{TripID: "T2", StopID: "B", DepartureTime: "09:00:00", ArrivalTime: "09:00:00", StopSequence: 1},
+		{TripID: "T2", StopID: "C", DepartureTime: "09:30:00", ArrivalTime: "09:30:00", StopSequence: 2},
+		{TripID: "T3", StopID: "C", DepartureTime: "10:00:00", ArrivalTime: "10:00:00", StopSequence: 1},
+		{TripID: "T3", StopID: "D", DepartureTime: "10:30:00", ArrivalTime: "10:30:00", StopSequence: 2},
+	}
+	store := newTestStore(date.Weekday(), trips, stopTimes)
+
+	const minTransferSeconds = 1800 // exactly matches the 30-minute gaps
+
+	itineraries := planJourney(store, date, "A", "D", 0, 2, minTransferSeconds)
+	if len(itineraries) == 0 {
+		t.Fatalf("expected an itinerary with maxTransfers=2, got none")
+	}
+	found := itineraries[len(itineraries)-1]
+	if found.Transfers != 2 {
+		t.Fatalf("expected a 2-transfer itinerary, got %d transfers", found.Transfers)
+	}
+	if len(found.Legs) != 3 {
+		t.Fatalf("expected 3 legs, got %d", len(found.Legs))
+	}
+
+	if itineraries := planJourney(store, date, "A", "D", 0, 1, minTransferSeconds); len(itineraries) != 0 {
+		t.Fatalf("expected no itinerary with maxTransfers=1, got %d", len(itineraries))
+	}
+}
@@ -72,9 +72,18 @@ type TrainInfoResponse struct {
 }
 
 type TrainStop struct {
-	StopName      string `json:"stop_name"`
-	ArrivalTime   string `json:"arrival_time"`
-	DepartureTime string `json:"departure_time"`
+	StopName string `json:"stop_name"`
+	// ArrivalTime and DepartureTime are kept for backward compatibility with
+	// existing /getTrainInfo consumers; ScheduledArrival/ScheduledDeparture
+	// below are the same values under the new names.
+	ArrivalTime        string `json:"arrival_time"`
+	DepartureTime      string `json:"departure_time"`
+	ScheduledArrival   string `json:"scheduled_arrival"`
+	ScheduledDeparture string `json:"scheduled_departure"`
+	ActualArrival      string `json:"actual_arrival,omitempty"`
+	ActualDeparture    string `json:"actual_departure,omitempty"`
+	DelaySeconds       int32  `json:"delay_seconds,omitempty"`
+	Canceled           bool   `json:"canceled,omitempty"`
 }
 
 // TrainListResponse defines the structure for a list of trains
@@ -179,10 +188,15 @@ func loadTrips() ([]Trip, error) {
 	return trips, nil
 }
 
-// Load calendar.txt for service dates
+// Load calendar.txt for service dates. calendar.txt is conditionally
+// required by the GTFS spec: a feed may ship calendar_dates.txt alone, so a
+// missing file is not an error.
 func loadCalendar() ([]Calendar, error) {
 	file, err := os.Open("gtfs/calendar.txt")
 	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
 		return nil, err
 	}
 	defer file.Close()
@@ -216,28 +230,85 @@ func loadCalendar() ([]Calendar, error) {
 	return calendars, nil
 }
 
-// Check if a trip is valid on a given date
-func isValidTrip(trip Trip, date time.Time, calendars []Calendar) bool {
-	for _, cal := range calendars {
-		if cal.ServiceID == trip.ServiceID {
-			// Check if the date is within the valid date range
-			startDate, _ := time.Parse("20060102", cal.StartDate)
-			endDate, _ := time.Parse("20060102", cal.EndDate)
-
-			if date.Before(startDate) || date.After(endDate) {
-				log.Printf("Trip %s not valid on %s: outside service date range (%s - %s)\n", trip.TripID, date, startDate, endDate)
-				continue
-			}
+// CalendarDate is a single row of calendar_dates.txt: an exception to the
+// regular weekday service described by calendar.txt.
+type CalendarDate struct {
+	ServiceID     string `json:"service_id"`
+	Date          string `json:"date"`           // YYYYMMDD
+	ExceptionType int    `json:"exception_type"` // 1 = service added, 2 = service removed
+}
 
-			// Check if the service runs on the day of the week
-			weekday := date.Weekday()
-			if cal.Weekdays[weekday] {
-				return true
-			} else {
-				log.Printf("Trip %s not valid on %s: service does not run on %s\n", trip.TripID, date, weekday)
-			}
+// Load calendar_dates.txt for service exceptions (added/removed days). The
+// file is optional: a feed may rely on calendar.txt alone.
+func loadCalendarDates() ([]CalendarDate, error) {
+	file, err := os.Open("gtfs/calendar_dates.txt")
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	var calendarDates []CalendarDate
+	for _, record := range records[1:] {
+		if len(record) < 3 {
+			continue
 		}
+
+		exceptionType, _ := strconv.Atoi(record[2])
+		calendarDates = append(calendarDates, CalendarDate{
+			ServiceID:     record[0],
+			Date:          record[1],
+			ExceptionType: exceptionType,
+		})
 	}
+	return calendarDates, nil
+}
+
+// Check if a trip is valid on a given date
+func isValidTrip(trip Trip, date time.Time, store *GTFSStore) bool {
+	dateStr := date.Format("20060102")
+
+	// calendar_dates.txt exceptions take precedence over calendar.txt,
+	// and can stand on their own for feeds with no calendar.txt row.
+	if exceptionType, ok := store.CalendarException(trip.ServiceID, dateStr); ok {
+		switch exceptionType {
+		case 2:
+			log.Printf("Trip %s not valid on %s: removed by calendar_dates exception\n", trip.TripID, date)
+			return false
+		case 1:
+			return true
+		}
+	}
+
+	cal, ok := store.Calendar(trip.ServiceID)
+	if !ok {
+		return false
+	}
+
+	// Check if the date is within the valid date range
+	startDate, _ := time.Parse("20060102", cal.StartDate)
+	endDate, _ := time.Parse("20060102", cal.EndDate)
+
+	if date.Before(startDate) || date.After(endDate) {
+		log.Printf("Trip %s not valid on %s: outside service date range (%s - %s)\n", trip.TripID, date, startDate, endDate)
+		return false
+	}
+
+	// Check if the service runs on the day of the week. Weekdays is
+	// Monday-first, while time.Weekday counts from Sunday.
+	weekday := date.Weekday()
+	if cal.Weekdays[(int(weekday)+6)%7] {
+		return true
+	}
+	log.Printf("Trip %s not valid on %s: service does not run on %s\n", trip.TripID, date, weekday)
 	return false
 }
 
@@ -250,71 +321,56 @@ func handleGetTrainInfo(w http.ResponseWriter, r *http.Request) {
 	// Parse the date and validate
 	parsedDate, err := time.Parse("2006-01-02", date)
 	if err != nil {
-		http.Error(w, "Invalid date format. Use YYYY-MM-DD", http.StatusBadRequest)
-		return
-	}
-
-	// Load GTFS data
-	stops, err := loadStops()
-	if err != nil {
-		http.Error(w, "Error loading stops", http.StatusInternalServerError)
-		return
-	}
-
-	stopTimes, err := loadStopTimes()
-	if err != nil {
-		http.Error(w, "Error loading stop times", http.StatusInternalServerError)
+		writeError(w, newHTTPError(http.StatusBadRequest, ErrCodeInvalidDate, "Invalid date format. Use YYYY-MM-DD"))
 		return
 	}
 
-	trips, err := loadTrips()
-	if err != nil {
-		http.Error(w, "Error loading trips", http.StatusInternalServerError)
-		return
-	}
-
-	calendars, err := loadCalendar()
-	if err != nil {
-		http.Error(w, "Error loading calendar", http.StatusInternalServerError)
-		return
-	}
+	store := gtfsStore.Get()
 
 	// Filter trips by train number
 	var matchingTrips []Trip
-	for _, trip := range trips {
-		if trip.TripID == trainNumber && isValidTrip(trip, parsedDate, calendars) {
+	for _, trip := range store.Trips() {
+		if trip.TripID == trainNumber && isValidTrip(trip, parsedDate, store) {
 			matchingTrips = append(matchingTrips, trip)
 		}
 	}
 
 	// If no trips found for the train number
 	if len(matchingTrips) == 0 {
-		http.Error(w, "No trips found for this train number and date", http.StatusNotFound)
+		writeError(w, newHTTPError(http.StatusNotFound, ErrCodeNoTrips, "No trips found for this train number and date"))
 		return
 	}
 
+	var overlay *RealtimeOverlay
+	if wantsRealtime(r) {
+		overlay = realtime.Get()
+	}
+
 	var stopsWithTimes []TrainStop
 	for _, trip := range matchingTrips {
-		for _, stopTime := range stopTimes {
-			if stopTime.TripID == trip.TripID {
-				stop := stops[stopTime.StopID]
-				arrivalTime := AdjustTime(stopTime.ArrivalTime)
-				departureTime := AdjustTime(stopTime.DepartureTime)
-
-				// Check for empty times and set placeholders if necessary
-				if arrivalTime == "" {
-					arrivalTime = "N/A" // or any placeholder you prefer
-				}
-				if departureTime == "" {
-					departureTime = "N/A" // or any placeholder you prefer
-				}
-
-				stopsWithTimes = append(stopsWithTimes, TrainStop{
-					StopName:      stop.StopName,
-					ArrivalTime:   arrivalTime,
-					DepartureTime: departureTime,
-				})
+		for _, stopTime := range store.StopTimesForTrip(trip.TripID) {
+			stop, _ := store.Stop(stopTime.StopID)
+			arrivalTime := AdjustTime(stopTime.ArrivalTime)
+			departureTime := AdjustTime(stopTime.DepartureTime)
+
+			// Check for empty times and set placeholders if necessary
+			if arrivalTime == "" {
+				arrivalTime = "N/A" // or any placeholder you prefer
 			}
+			if departureTime == "" {
+				departureTime = "N/A" // or any placeholder you prefer
+			}
+
+			ts := TrainStop{
+				StopName:           stop.StopName,
+				ArrivalTime:        arrivalTime,
+				DepartureTime:      departureTime,
+				ScheduledArrival:   arrivalTime,
+				ScheduledDeparture: departureTime,
+			}
+			applyRealtimeOverlay(&ts, overlay, trip.TripID, stopTime.StopID, stopTime.StopSequence)
+
+			stopsWithTimes = append(stopsWithTimes, ts)
 		}
 	}
 
@@ -341,49 +397,30 @@ func handleGetTrainList(w http.ResponseWriter, r *http.Request) {
 	// Parse the date and validate
 	parsedDate, err := time.Parse("2006-01-02", date)
 	if err != nil {
-		http.Error(w, "Invalid date format. Use YYYY-MM-DD", http.StatusBadRequest)
-		return
-	}
-
-	// Load GTFS data
-	stops, err := loadStops()
-	if err != nil {
-		http.Error(w, "Error loading stops", http.StatusInternalServerError)
-		return
-	}
-
-	stopTimes, err := loadStopTimes()
-	if err != nil {
-		http.Error(w, "Error loading stop times", http.StatusInternalServerError)
+		writeError(w, newHTTPError(http.StatusBadRequest, ErrCodeInvalidDate, "Invalid date format. Use YYYY-MM-DD"))
 		return
 	}
 
-	trips, err := loadTrips()
-	if err != nil {
-		http.Error(w, "Error loading trips", http.StatusInternalServerError)
-		return
-	}
+	store := gtfsStore.Get()
 
-	calendars, err := loadCalendar()
-	if err != nil {
-		http.Error(w, "Error loading calendar", http.StatusInternalServerError)
-		return
-	}
-
-	// Find stop IDs for departure and arrival stations
-	var departureID, arrivalID string
-	for _, stop := range stops {
-		if stop.StopName == departureStation {
-			departureID = stop.StopID
+	// Find stop IDs for departure and arrival stations, preferring an
+	// explicit stopID over a name lookup when the caller already has one.
+	departureID := r.URL.Query().Get("departureStopID")
+	if departureID == "" {
+		if ids := store.StopIDsByName(departureStation); len(ids) > 0 {
+			departureID = ids[0]
 		}
-		if stop.StopName == arrivalStation {
-			arrivalID = stop.StopID
+	}
+	arrivalID := r.URL.Query().Get("arrivalStopID")
+	if arrivalID == "" {
+		if ids := store.StopIDsByName(arrivalStation); len(ids) > 0 {
+			arrivalID = ids[0]
 		}
 	}
 
 	// If either station is not found
 	if departureID == "" || arrivalID == "" {
-		http.Error(w, "Departure or Arrival station not found", http.StatusNotFound)
+		writeError(w, newHTTPError(http.StatusNotFound, ErrCodeStationNotFound, "Departure or Arrival station not found"))
 		return
 	}
 
@@ -392,28 +429,38 @@ func handleGetTrainList(w http.ResponseWriter, r *http.Request) {
 		TripID        string `json:"trip_id"`
 		DepartureTime string `json:"departure_time"`
 		ArrivalTime   string `json:"arrival_time"`
+		DelaySeconds  int32  `json:"delay_seconds,omitempty"`
+		Canceled      bool   `json:"canceled,omitempty"`
+	}
+
+	var overlay *RealtimeOverlay
+	if wantsRealtime(r) {
+		overlay = realtime.Get()
 	}
 
 	var trainsWithStops []TrainInfo
 
-	for _, trip := range trips {
-		if !isValidTrip(trip, parsedDate, calendars) {
+	for _, trip := range store.Trips() {
+		if !isValidTrip(trip, parsedDate, store) {
 			continue
 		}
 
+		tripStopTimes := store.StopTimesForTrip(trip.TripID)
+
 		var departureTime, arrivalTime string
+		var departureStopTime, arrivalStopTime StopTime
 		var hasDeparture, hasArrival bool
 
-		for _, stopTime := range stopTimes {
-			if stopTime.TripID == trip.TripID {
-				if stopTime.StopID == departureID {
-					hasDeparture = true
-					departureTime = AdjustTime(stopTime.DepartureTime) // Adjust time here
-				}
-				if stopTime.StopID == arrivalID {
-					hasArrival = true
-					arrivalTime = AdjustTime(stopTime.ArrivalTime) // Adjust time here
-				}
+		for _, stopTime := range tripStopTimes {
+			if stopTime.StopID == departureID {
+				hasDeparture = true
+				departureStopTime = stopTime
+				departureTime = AdjustTime(stopTime.DepartureTime) // Adjust time here
+			}
+			if stopTime.StopID == arrivalID {
+				hasArrival = true
+				arrivalStopTime = stopTime
+				arrivalTime = AdjustTime(stopTime.ArrivalTime) // Adjust time here
 			}
 			// Check if we found both departure and arrival, and stop if we already have both
 			if hasDeparture && hasArrival {
@@ -424,14 +471,20 @@ func handleGetTrainList(w http.ResponseWriter, r *http.Request) {
 		// Check if we have valid departure and arrival times and if the stops are in the correct order
 		if hasDeparture && hasArrival && departureTime != "" && arrivalTime != "" {
 			// Ensure the stop order is correct by comparing indices
-			departureIndex := findStopIndex(trip.TripID, departureID, stopTimes)
-			arrivalIndex := findStopIndex(trip.TripID, arrivalID, stopTimes)
+			departureIndex := findStopIndex(departureID, tripStopTimes)
+			arrivalIndex := findStopIndex(arrivalID, tripStopTimes)
 
 			if departureIndex < arrivalIndex {
+				ts := TrainStop{}
+				applyRealtimeOverlay(&ts, overlay, trip.TripID, departureStopTime.StopID, departureStopTime.StopSequence)
+				applyRealtimeOverlay(&ts, overlay, trip.TripID, arrivalStopTime.StopID, arrivalStopTime.StopSequence)
+
 				trainsWithStops = append(trainsWithStops, TrainInfo{
 					TripID:        trip.TripID,   // Store the trip ID
 					DepartureTime: departureTime, // Departure time
 					ArrivalTime:   arrivalTime,   // Arrival time
+					DelaySeconds:  ts.DelaySeconds,
+					Canceled:      ts.Canceled,
 				})
 			}
 		}
@@ -439,7 +492,7 @@ func handleGetTrainList(w http.ResponseWriter, r *http.Request) {
 
 	// If no trains found
 	if len(trainsWithStops) == 0 {
-		http.Error(w, "No trains found containing both specified stations", http.StatusNotFound)
+		writeError(w, newHTTPError(http.StatusNotFound, ErrCodeNoTrips, "No trains found containing both specified stations"))
 		return
 	}
 
@@ -460,22 +513,52 @@ func handleGetTrainList(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
-// findStopIndex finds the index of a stop in the stopTimes slice for a specific tripID
-func findStopIndex(tripID string, stopID string, stopTimes []StopTime) int {
+// findStopIndex finds the index of a stop within a trip's (already filtered)
+// stop times slice.
+func findStopIndex(stopID string, stopTimes []StopTime) int {
 	for i, stopTime := range stopTimes {
-		if stopTime.TripID == tripID && stopTime.StopID == stopID {
+		if stopTime.StopID == stopID {
 			return i
 		}
 	}
 	return -1 // Not found
 }
 
+// gtfsStore is the process-wide, periodically refreshed GTFS index used by
+// every handler.
+var gtfsStore *GTFSStoreManager
+
+// realtime is the process-wide GTFS-Realtime overlay. It stays nil when
+// REALTIME_FEED_URL is unset, which disables the overlay everywhere.
+var realtime *RealtimeManager
+
 func main() {
+	store, _, err := NewGTFSStoreManager(gtfsRefreshInterval())
+	if err != nil {
+		log.Fatalf("Failed to load GTFS feed: %v", err)
+	}
+	gtfsStore = store
+
+	if feedURL := realtimeFeedURL(); feedURL != "" {
+		rt, _, err := NewRealtimeManager(feedURL, realtimeRefreshInterval())
+		if err != nil {
+			// The realtime overlay is optional everywhere it's consumed (a nil
+			// *RealtimeManager is a valid, always-disabled source), so a failed
+			// initial fetch shouldn't take down the static timetable endpoints.
+			log.Printf("Failed to load GTFS-Realtime feed, continuing without realtime data: %v", err)
+		} else {
+			realtime = rt
+		}
+	}
+
 	http.HandleFunc("/getTrainInfo", handleGetTrainInfo)
 	http.HandleFunc("/getTrainList", handleGetTrainList)
+	http.HandleFunc("/planJourney", handlePlanJourney)
+	http.HandleFunc("/searchStops", handleSearchStops)
+	http.HandleFunc("/health", handleHealth)
 
 	log.Println("Starting server on :8080")
-	err := http.ListenAndServe(":8080", nil)
+	err = http.ListenAndServe(":8080", nil)
 	if err != nil {
 		log.Fatalf("Server failed to start: %v", err)
 	}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// TestIsValidTripCalendarExceptions checks that calendar_dates.txt exceptions
+// override calendar.txt in both directions: type 2 removes a day the weekly
+// pattern would otherwise run, and type 1 adds a day it otherwise wouldn't.
+func TestIsValidTripCalendarExceptions(t *testing.T) {
+	monday := time.Date(2026, 1, 5, 0, 0, 0, 0, time.UTC) // runs per calendar.txt
+	tuesday := time.Date(2026, 1, 6, 0, 0, 0, 0, time.UTC) // doesn't run per calendar.txt
+
+	var weekdays [7]bool
+	weekdays[(int(monday.Weekday())+6)%7] = true // Weekdays is Monday-first
+
+	trip := Trip{TripID: "T1", RouteID: "R1", ServiceID: "S1"}
+
+	baseStore := func(exceptions map[serviceDateKey]int) *GTFSStore {
+		return &GTFSStore{
+			tripsByID: map[string]Trip{"T1": trip},
+			calendarsByService: map[string]Calendar{
+				"S1": {
+					ServiceID: "S1",
+					StartDate: "20250101",
+					EndDate:   "20261231",
+					Weekdays:  weekdays,
+				},
+			},
+			calendarExceptions: exceptions,
+		}
+	}
+
+	t.Run("runs on its scheduled weekday with no exception", func(t *testing.T) {
+		store := baseStore(map[serviceDateKey]int{})
+		if !isValidTrip(trip, monday, store) {
+			t.Fatalf("expected trip to run on its scheduled weekday")
+		}
+	})
+
+	t.Run("type 2 exception removes a scheduled day", func(t *testing.T) {
+		store := baseStore(map[serviceDateKey]int{
+			{ServiceID: "S1", Date: monday.Format("20060102")}: 2,
+		})
+		if isValidTrip(trip, monday, store) {
+			t.Fatalf("expected type 2 exception to override calendar.txt and remove service")
+		}
+	})
+
+	t.Run("type 1 exception adds an otherwise-unscheduled day", func(t *testing.T) {
+		store := baseStore(map[serviceDateKey]int{
+			{ServiceID: "S1", Date: tuesday.Format("20060102")}: 1,
+		})
+		if !isValidTrip(trip, tuesday, store) {
+			t.Fatalf("expected type 1 exception to override calendar.txt and add service")
+		}
+	})
+
+	t.Run("unscheduled day with no exception does not run", func(t *testing.T) {
+		store := baseStore(map[serviceDateKey]int{})
+		if isValidTrip(trip, tuesday, store) {
+			t.Fatalf("expected trip not to run on an unscheduled day with no exception")
+		}
+	})
+}
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/MobilityData/gtfs-realtime-bindings/golang/gtfs"
+	"google.golang.org/protobuf/proto"
+)
+
+// defaultRealtimeRefreshInterval is how often the TripUpdates feed is
+// re-fetched when no REALTIME_REFRESH_INTERVAL override is set.
+const defaultRealtimeRefreshInterval = 30 * time.Second
+
+// tripStopKey identifies a single scheduled stop visit, matching the
+// (trip_id, stop_id, stop_sequence) triple GTFS-Realtime uses to target a
+// StopTimeUpdate.
+type tripStopKey struct {
+	TripID       string
+	StopID       string
+	StopSequence int
+}
+
+// tripStopPairKey identifies a scheduled stop visit by (trip_id, stop_id)
+// alone, used as a fallback for feeds that omit stop_sequence because
+// stop_id is unambiguous within the trip.
+type tripStopPairKey struct {
+	TripID string
+	StopID string
+}
+
+// StopTimeUpdate is the overlay applied to one scheduled stop visit.
+type StopTimeUpdate struct {
+	ArrivalDelaySeconds   int32
+	ArrivalTime           int64 // Unix seconds; 0 if the feed only gave a delay
+	DepartureDelaySeconds int32
+	DepartureTime         int64
+	Skipped               bool
+}
+
+// RealtimeOverlay is a snapshot of the most recently fetched TripUpdates
+// feed, indexed for O(1) lookup by handlers.
+type RealtimeOverlay struct {
+	fetchedAt     time.Time
+	feedTimestamp time.Time
+	// stopUpdates holds updates the feed targeted with a stop_sequence.
+	// stopUpdatesByStop holds updates for (spec-legal) feeds that omitted
+	// stop_sequence because stop_id alone was unambiguous within the trip.
+	stopUpdates       map[tripStopKey]StopTimeUpdate
+	stopUpdatesByStop map[tripStopPairKey]StopTimeUpdate
+	canceledTrips     map[string]bool
+}
+
+// StopUpdate returns the overlay for a scheduled stop visit, if the feed
+// carries one. It first tries an exact (trip_id, stop_id, stop_sequence)
+// match, then falls back to (trip_id, stop_id) for updates the feed sent
+// without a stop_sequence.
+func (o *RealtimeOverlay) StopUpdate(tripID, stopID string, stopSequence int) (StopTimeUpdate, bool) {
+	if o == nil {
+		return StopTimeUpdate{}, false
+	}
+	if u, ok := o.stopUpdates[tripStopKey{tripID, stopID, stopSequence}]; ok {
+		return u, true
+	}
+	u, ok := o.stopUpdatesByStop[tripStopPairKey{tripID, stopID}]
+	return u, ok
+}
+
+// TripCanceled reports whether the whole trip was marked CANCELED.
+func (o *RealtimeOverlay) TripCanceled(tripID string) bool {
+	return o != nil && o.canceledTrips[tripID]
+}
+
+// Age returns how long ago this overlay was fetched, used to report feed
+// staleness from /health.
+func (o *RealtimeOverlay) Age() time.Duration {
+	if o == nil {
+		return 0
+	}
+	return time.Since(o.fetchedAt)
+}
+
+// RealtimeManager fetches a GTFS-Realtime TripUpdates feed on an interval and
+// exposes the latest parsed overlay. A nil *RealtimeManager is a valid,
+// always-disabled overlay source, so handlers don't need to nil-check it
+// before calling Get().
+type RealtimeManager struct {
+	feedURL string
+	current atomic.Pointer[RealtimeOverlay]
+}
+
+// NewRealtimeManager starts fetching feedURL every interval in the
+// background. The first fetch happens synchronously so Get() never returns
+// nil once this returns without error.
+func NewRealtimeManager(feedURL string, interval time.Duration) (mgr *RealtimeManager, stop func(), err error) {
+	mgr = &RealtimeManager{feedURL: feedURL}
+
+	overlay, err := fetchRealtimeOverlay(feedURL)
+	if err != nil {
+		return nil, nil, err
+	}
+	mgr.current.Store(overlay)
+
+	done := make(chan struct{})
+	go mgr.refreshLoop(interval, done)
+
+	return mgr, func() { close(done) }, nil
+}
+
+// Get returns the most recently fetched overlay. Safe for concurrent use.
+func (m *RealtimeManager) Get() *RealtimeOverlay {
+	if m == nil {
+		return nil
+	}
+	return m.current.Load()
+}
+
+func (m *RealtimeManager) refreshLoop(interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			overlay, err := fetchRealtimeOverlay(m.feedURL)
+			if err != nil {
+				log.Printf("realtime: refresh failed: %v", err)
+				continue
+			}
+			m.current.Store(overlay)
+		case <-done:
+			return
+		}
+	}
+}
+
+// fetchRealtimeOverlay downloads and decodes a GTFS-Realtime TripUpdates
+// feed into an indexed RealtimeOverlay.
+func fetchRealtimeOverlay(feedURL string) (*RealtimeOverlay, error) {
+	resp, err := http.Get(feedURL)
+	if err != nil {
+		return nil, fmt.Errorf("fetching realtime feed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching realtime feed: status %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading realtime feed: %w", err)
+	}
+
+	var feed gtfs.FeedMessage
+	if err := proto.Unmarshal(body, &feed); err != nil {
+		return nil, fmt.Errorf("decoding realtime feed: %w", err)
+	}
+
+	overlay := &RealtimeOverlay{
+		fetchedAt:         time.Now(),
+		stopUpdates:       make(map[tripStopKey]StopTimeUpdate),
+		stopUpdatesByStop: make(map[tripStopPairKey]StopTimeUpdate),
+		canceledTrips:     make(map[string]bool),
+	}
+	if feed.Header != nil && feed.Header.Timestamp != nil {
+		overlay.feedTimestamp = time.Unix(int64(*feed.Header.Timestamp), 0)
+	}
+
+	for _, entity := range feed.Entity {
+		tu := entity.TripUpdate
+		if tu == nil || tu.Trip == nil || tu.Trip.TripId == nil {
+			continue
+		}
+		tripID := tu.Trip.GetTripId()
+
+		if tu.Trip.ScheduleRelationship != nil &&
+			tu.Trip.GetScheduleRelationship() == gtfs.TripDescriptor_CANCELED {
+			overlay.canceledTrips[tripID] = true
+			continue
+		}
+
+		for _, stu := range tu.StopTimeUpdate {
+			if stu.StopId == nil {
+				continue
+			}
+
+			update := StopTimeUpdate{
+				Skipped: stu.ScheduleRelationship != nil &&
+					stu.GetScheduleRelationship() == gtfs.TripUpdate_StopTimeUpdate_SKIPPED,
+			}
+			if stu.Arrival != nil {
+				update.ArrivalDelaySeconds = stu.Arrival.GetDelay()
+				update.ArrivalTime = stu.Arrival.GetTime()
+			}
+			if stu.Departure != nil {
+				update.DepartureDelaySeconds = stu.Departure.GetDelay()
+				update.DepartureTime = stu.Departure.GetTime()
+			}
+
+			// stop_sequence is only required by the spec when stop_id alone is
+			// ambiguous within the trip, so some feeds omit it; fall back to
+			// indexing by (trip_id, stop_id) for those updates.
+			if stu.StopSequence != nil {
+				key := tripStopKey{
+					TripID:       tripID,
+					StopID:       stu.GetStopId(),
+					StopSequence: int(stu.GetStopSequence()),
+				}
+				overlay.stopUpdates[key] = update
+			} else {
+				key := tripStopPairKey{TripID: tripID, StopID: stu.GetStopId()}
+				overlay.stopUpdatesByStop[key] = update
+			}
+		}
+	}
+
+	return overlay, nil
+}
+
+// realtimeFeedURL resolves the TripUpdates feed URL from REALTIME_FEED_URL.
+// An empty result means the overlay is disabled.
+func realtimeFeedURL() string {
+	return os.Getenv("REALTIME_FEED_URL")
+}
+
+// realtimeRefreshInterval resolves the refresh interval from
+// REALTIME_REFRESH_INTERVAL (a duration string such as "15s"), falling back
+// to the default.
+func realtimeRefreshInterval() time.Duration {
+	if raw := os.Getenv("REALTIME_REFRESH_INTERVAL"); raw != "" {
+		if d, err := time.ParseDuration(raw); err == nil {
+			return d
+		}
+		log.Printf("realtime: invalid REALTIME_REFRESH_INTERVAL %q, using default %s", raw, defaultRealtimeRefreshInterval)
+	}
+	return defaultRealtimeRefreshInterval
+}
+
+// wantsRealtime reports whether a request opted out via ?realtime=false.
+func wantsRealtime(r *http.Request) bool {
+	return r.URL.Query().Get("realtime") != "false"
+}
+
+// applyRealtimeOverlay fills in the Actual* fields of a TrainStop from the
+// overlay, leaving them unset (and DelaySeconds 0, Canceled false) when the
+// feed has nothing for this stop visit. Actual* are set only from absolute
+// times in the feed; an update that carries only a delay sets DelaySeconds
+// and leaves ActualArrival/ActualDeparture unset.
+func applyRealtimeOverlay(ts *TrainStop, overlay *RealtimeOverlay, tripID, stopID string, stopSequence int) {
+	if overlay == nil {
+		return
+	}
+
+	if overlay.TripCanceled(tripID) {
+		ts.Canceled = true
+		return
+	}
+
+	update, ok := overlay.StopUpdate(tripID, stopID, stopSequence)
+	if !ok {
+		return
+	}
+
+	if update.Skipped {
+		ts.Canceled = true
+		return
+	}
+
+	if update.ArrivalTime != 0 {
+		ts.ActualArrival = time.Unix(update.ArrivalTime, 0).Format("15:04:05")
+	}
+	if update.DepartureTime != 0 {
+		ts.ActualDeparture = time.Unix(update.DepartureTime, 0).Format("15:04:05")
+	}
+	if update.DepartureDelaySeconds != 0 {
+		ts.DelaySeconds = update.DepartureDelaySeconds
+	} else if update.ArrivalDelaySeconds != 0 {
+		ts.DelaySeconds = update.ArrivalDelaySeconds
+	}
+}
+
+// handleHealth reports whether the realtime overlay is enabled and, if so,
+// the age of the realtime feed, so staleness is visible to operators. The
+// static GTFS feed's age is not reported.
+func handleHealth(w http.ResponseWriter, r *http.Request) {
+	health := struct {
+		Status              string  `json:"status"`
+		RealtimeEnabled     bool    `json:"realtime_enabled"`
+		RealtimeFeedAgeSecs float64 `json:"realtime_feed_age_seconds,omitempty"`
+	}{
+		Status: "ok",
+	}
+
+	if overlay := realtime.Get(); overlay != nil {
+		health.RealtimeEnabled = true
+		health.RealtimeFeedAgeSecs = overlay.Age().Seconds()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(health)
+}
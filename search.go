@@ -0,0 +1,209 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	defaultSearchLimit          = 10
+	fuzzyMatchMinQueryLen       = 4
+	fuzzyMatchDistanceThreshold = 2
+)
+
+// foldStopName case-folds and strips diacritics so "Gare de Lyon" and
+// "gare de lyon" and accented variants all compare equal.
+func foldStopName(s string) string {
+	t := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	folded, _, err := transform.String(t, strings.ToLower(s))
+	if err != nil {
+		return strings.ToLower(s)
+	}
+	return folded
+}
+
+// searchStops ranks stops against a query: case-folded/diacritic-stripped
+// prefix matches first, then Damerau-Levenshtein typo-tolerant matches
+// against the stop name's individual words.
+func searchStops(store *GTFSStore, query string, limit int) []Stop {
+	foldedQuery := foldStopName(query)
+	queryWords := strings.Fields(foldedQuery)
+
+	type candidate struct {
+		stop     Stop
+		isPrefix bool
+		distance int
+	}
+
+	var candidates []candidate
+	for _, stop := range store.Stops() {
+		foldedName := foldStopName(stop.StopName)
+
+		if strings.HasPrefix(foldedName, foldedQuery) {
+			candidates = append(candidates, candidate{stop: stop, isPrefix: true})
+			continue
+		}
+
+		if len(foldedQuery) < fuzzyMatchMinQueryLen {
+			continue
+		}
+
+		nameWords := strings.Fields(foldedName)
+		distance, ok := bestWordwiseDistance(queryWords, nameWords)
+		if ok && distance <= fuzzyMatchDistanceThreshold {
+			candidates = append(candidates, candidate{stop: stop, distance: distance})
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].isPrefix != candidates[j].isPrefix {
+			return candidates[i].isPrefix
+		}
+		if !candidates[i].isPrefix {
+			return candidates[i].distance < candidates[j].distance
+		}
+		return len(candidates[i].stop.StopName) < len(candidates[j].stop.StopName)
+	})
+
+	if limit > 0 && len(candidates) > limit {
+		candidates = candidates[:limit]
+	}
+
+	stops := make([]Stop, len(candidates))
+	for i, c := range candidates {
+		stops[i] = c.stop
+	}
+	return stops
+}
+
+// bestWordwiseDistance matches each query word to its closest name word and
+// returns the worst of those per-word distances, so a multi-word query only
+// counts as close when every one of its words is close to some name word
+// (not just the one word that happens to match best).
+func bestWordwiseDistance(queryWords, nameWords []string) (int, bool) {
+	if len(queryWords) == 0 || len(nameWords) == 0 {
+		return 0, false
+	}
+
+	worst := -1
+	for _, queryWord := range queryWords {
+		best := -1
+		for _, nameWord := range nameWords {
+			d := damerauLevenshtein(queryWord, nameWord)
+			if best == -1 || d < best {
+				best = d
+			}
+		}
+		if best > worst {
+			worst = best
+		}
+	}
+	return worst, true
+}
+
+// damerauLevenshtein returns the true Damerau-Levenshtein distance (allowing
+// insertions, deletions, substitutions, and adjacent transpositions) between
+// two strings.
+func damerauLevenshtein(a, b string) int {
+	ra := []rune(a)
+	rb := []rune(b)
+	lenA, lenB := len(ra), len(rb)
+	if lenA == 0 {
+		return lenB
+	}
+	if lenB == 0 {
+		return lenA
+	}
+
+	maxDist := lenA + lenB
+	lastRow := make(map[rune]int)
+
+	d := make([][]int, lenA+2)
+	for i := range d {
+		d[i] = make([]int, lenB+2)
+	}
+	d[0][0] = maxDist
+	for i := 0; i <= lenA; i++ {
+		d[i+1][0] = maxDist
+		d[i+1][1] = i
+	}
+	for j := 0; j <= lenB; j++ {
+		d[0][j+1] = maxDist
+		d[1][j+1] = j
+	}
+
+	for i := 1; i <= lenA; i++ {
+		lastMatchCol := 0
+		for j := 1; j <= lenB; j++ {
+			i1 := lastRow[rb[j-1]]
+			j1 := lastMatchCol
+
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+				lastMatchCol = j
+			}
+
+			d[i+1][j+1] = minOf(
+				d[i][j]+cost,
+				d[i+1][j]+1,
+				d[i][j+1]+1,
+				d[i1][j1]+(i-i1-1)+1+(j-j1-1),
+			)
+		}
+		lastRow[ra[i-1]] = i
+	}
+
+	return d[lenA+1][lenB+1]
+}
+
+func minOf(values ...int) int {
+	min := values[0]
+	for _, v := range values[1:] {
+		if v < min {
+			min = v
+		}
+	}
+	return min
+}
+
+// handleSearchStops answers /searchStops?q=...&limit=10
+func handleSearchStops(w http.ResponseWriter, r *http.Request) {
+	log.Println("request received for /searchStops")
+
+	query := r.URL.Query().Get("q")
+	if query == "" {
+		writeError(w, newHTTPError(http.StatusBadRequest, ErrCodeMissingParameter, "Missing required query parameter q"))
+		return
+	}
+
+	limit := defaultSearchLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		if n, err := strconv.Atoi(raw); err == nil && n > 0 {
+			limit = n
+		}
+	}
+
+	store := gtfsStore.Get()
+	matches := searchStops(store, query, limit)
+
+	response := struct {
+		Query string `json:"query"`
+		Stops []Stop `json:"stops"`
+	}{
+		Query: query,
+		Stops: matches,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}